@@ -0,0 +1,18 @@
+//go:build !windows
+
+package hostsFile
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwnership copies the uid/gid of the original /etc/hosts
+// onto the replacement file before it's renamed into place.
+func preserveOwnership(path string, origInfo os.FileInfo) {
+	stat, ok := origInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	_ = os.Chown(path, int(stat.Uid), int(stat.Gid))
+}
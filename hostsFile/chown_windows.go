@@ -0,0 +1,8 @@
+//go:build windows
+
+package hostsFile
+
+import "os"
+
+// Windows has no POSIX uid/gid to preserve.
+func preserveOwnership(string, os.FileInfo) {}
@@ -1,28 +1,194 @@
 package hostsFile
 
 import (
-	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-func WriteHostsFile(domains []string) error {
-	file, err := os.OpenFile("/etc/hosts", os.O_APPEND|os.O_WRONLY, 0644)
+const (
+	hostsPath  = "/etc/hosts"
+	backupPath = "/etc/hosts.echo-proxy.bak"
+
+	blockStart = "# >>> echo-proxy managed block >>>"
+	blockEnd   = "# <<< echo-proxy managed block <<<"
+)
+
+// ErrNotPrivileged is returned when /etc/hosts can't be edited because
+// the process isn't running as root, so callers can detect it and
+// prompt for elevation instead of failing silently.
+var ErrNotPrivileged = errors.New("hostsFile: not running with sufficient privileges to edit /etc/hosts")
+
+// Sync replaces the proxy-managed block in /etc/hosts with exactly
+// the given domains (deduped and sorted), leaving the rest of the
+// file untouched. /etc/hosts is backed up first, and the write itself
+// is atomic, so repeated runs never pollute the file and a crash
+// mid-write can't corrupt it.
+func Sync(domains []string) error {
+	return rewrite(func(lines []string) []string {
+		return replaceBlock(lines, renderBlock(domains))
+	})
+}
+
+// Remove strips the managed block entirely.
+func Remove() error {
+	return rewrite(func(lines []string) []string {
+		return replaceBlock(lines, nil)
+	})
+}
+
+// Restore overwrites /etc/hosts with the backup taken by the most
+// recent Sync or Remove.
+func Restore() error {
+	if privileged, err := IsPrivileged(); err != nil || !privileged {
+		if err != nil {
+			return err
+		}
+		return ErrNotPrivileged
+	}
+
+	info, err := os.Stat(hostsPath)
+	if err != nil {
+		return err
+	}
+	backup, err := os.ReadFile(backupPath)
 	if err != nil {
 		return err
 	}
 
-	defer file.Close()
+	return atomicWrite(hostsPath, backup, info)
+}
 
-	data := bytes.NewBufferString("\n")
-	for _, line := range domains {
-		_, err = data.WriteString("127.0.0.1 " + line + " # Auto generated by local proxy\n")
+func rewrite(transform func([]string) []string) error {
+	if privileged, err := IsPrivileged(); err != nil || !privileged {
 		if err != nil {
 			return err
 		}
+		return ErrNotPrivileged
+	}
+
+	info, err := os.Stat(hostsPath)
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(hostsPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(backupPath, original, info.Mode()); err != nil {
+		return fmt.Errorf("back up %s: %w", hostsPath, err)
+	}
+
+	updated := transform(splitLines(original))
+	return atomicWrite(hostsPath, []byte(strings.Join(updated, "\n")+"\n"), info)
+}
+
+func splitLines(content []byte) []string {
+	text := strings.TrimRight(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// replaceBlock swaps the managed block in lines for newBlock (nil
+// removes it), appending the block at the end if it wasn't present.
+func replaceBlock(lines []string, newBlock []string) []string {
+	start, end := findBlock(lines)
+	if start == -1 {
+		if len(newBlock) == 0 {
+			return lines
+		}
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		return append(lines, newBlock...)
+	}
+
+	out := make([]string, 0, len(lines))
+	out = append(out, lines[:start]...)
+	out = append(out, newBlock...)
+	out = append(out, lines[end+1:]...)
+	return out
+}
+
+// findBlock returns the (inclusive) line range of the managed block,
+// or (-1, -1) if it isn't present.
+func findBlock(lines []string) (start, end int) {
+	start, end = -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case blockStart:
+			start = i
+		case blockEnd:
+			if start != -1 {
+				end = i
+			}
+		}
+	}
+	if start == -1 || end == -1 || end < start {
+		return -1, -1
+	}
+	return start, end
+}
+
+func renderBlock(domains []string) []string {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	deduped := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		deduped[d] = struct{}{}
+	}
+	sorted := make([]string, 0, len(deduped))
+	for d := range deduped {
+		sorted = append(sorted, d)
+	}
+	sort.Strings(sorted)
+
+	block := make([]string, 0, len(sorted)+2)
+	block = append(block, blockStart)
+	for _, d := range sorted {
+		block = append(block, "127.0.0.1 "+d)
+	}
+	block = append(block, blockEnd)
+	return block
+}
+
+// atomicWrite writes content to path via a temp file in the same
+// directory followed by a rename, so readers never see a half-written
+// /etc/hosts, and restores the original mode/ownership on the result.
+func atomicWrite(path string, content []byte, origInfo os.FileInfo) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Chmod(tmpPath, origInfo.Mode()); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
 	}
+	preserveOwnership(tmpPath, origInfo)
 
-	return nil
+	return os.Rename(tmpPath, path)
 }
 
 func IsPrivileged() (bool, error) {
@@ -0,0 +1,136 @@
+package hostsFile
+
+import "testing"
+
+func TestFindBlock(t *testing.T) {
+	cases := []struct {
+		name      string
+		lines     []string
+		wantStart int
+		wantEnd   int
+	}{
+		{"absent", []string{"127.0.0.1 localhost"}, -1, -1},
+		{
+			"present",
+			[]string{"127.0.0.1 localhost", blockStart, "127.0.0.1 foo.test", blockEnd, ""},
+			1, 3,
+		},
+		{"unterminated start only", []string{blockStart, "127.0.0.1 foo.test"}, -1, -1},
+		{"end without start", []string{blockEnd}, -1, -1},
+		{
+			"end before start is ignored",
+			[]string{blockEnd, blockStart, "127.0.0.1 foo.test", blockEnd},
+			1, 3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start, end := findBlock(c.lines)
+			if start != c.wantStart || end != c.wantEnd {
+				t.Errorf("findBlock() = (%d, %d), want (%d, %d)", start, end, c.wantStart, c.wantEnd)
+			}
+		})
+	}
+}
+
+func TestRenderBlock(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		if got := renderBlock(nil); got != nil {
+			t.Errorf("renderBlock(nil) = %v, want nil", got)
+		}
+	})
+
+	t.Run("dedupes and sorts", func(t *testing.T) {
+		got := renderBlock([]string{"b.test", "a.test", "b.test"})
+		want := []string{blockStart, "127.0.0.1 a.test", "127.0.0.1 b.test", blockEnd}
+		if len(got) != len(want) {
+			t.Fatalf("renderBlock() = %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("renderBlock()[%d] = %q, want %q", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+func TestReplaceBlockAppendsWhenAbsent(t *testing.T) {
+	lines := []string{"127.0.0.1 localhost"}
+	newBlock := renderBlock([]string{"foo.test"})
+
+	got := replaceBlock(lines, newBlock)
+	want := []string{"127.0.0.1 localhost", "", blockStart, "127.0.0.1 foo.test", blockEnd}
+
+	if len(got) != len(want) {
+		t.Fatalf("replaceBlock() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("replaceBlock()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReplaceBlockNoopWhenAbsentAndEmpty(t *testing.T) {
+	lines := []string{"127.0.0.1 localhost"}
+	got := replaceBlock(lines, nil)
+	if len(got) != 1 || got[0] != lines[0] {
+		t.Errorf("replaceBlock() = %v, want unchanged %v", got, lines)
+	}
+}
+
+func TestReplaceBlockSwapsExisting(t *testing.T) {
+	lines := []string{
+		"127.0.0.1 localhost",
+		blockStart,
+		"127.0.0.1 old.test",
+		blockEnd,
+		"",
+	}
+	newBlock := renderBlock([]string{"new.test"})
+
+	got := replaceBlock(lines, newBlock)
+	want := []string{"127.0.0.1 localhost", blockStart, "127.0.0.1 new.test", blockEnd, ""}
+
+	if len(got) != len(want) {
+		t.Fatalf("replaceBlock() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("replaceBlock()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReplaceBlockRemovesExisting(t *testing.T) {
+	lines := []string{
+		"127.0.0.1 localhost",
+		blockStart,
+		"127.0.0.1 old.test",
+		blockEnd,
+	}
+
+	got := replaceBlock(lines, nil)
+	want := []string{"127.0.0.1 localhost"}
+
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("replaceBlock() = %v, want %v", got, want)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	if got := splitLines([]byte("")); got != nil {
+		t.Errorf("splitLines(empty) = %v, want nil", got)
+	}
+	got := splitLines([]byte("a\nb\n"))
+	want := []string{"a", "b"}
+	if len(got) != len(want) {
+		t.Fatalf("splitLines() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("splitLines()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
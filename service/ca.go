@@ -0,0 +1,257 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"localProxy/userData"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	caCertFileName = "ca.crt"
+	caKeyFileName  = "ca.key"
+
+	// leafCertTTL is how long a minted leaf certificate is cached
+	// before it gets re-issued.
+	leafCertTTL = 24 * time.Hour
+)
+
+// certAuthority mints per-host leaf certificates on demand so users
+// don't have to generate and wire up a TLSCertFile/TLSKeyFile for
+// every proxied host. It is lazily created the first time a TLS
+// server is started and persisted next to config.json.
+type certAuthority struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+
+	mu    sync.RWMutex
+	cache map[string]*cachedLeaf
+}
+
+type cachedLeaf struct {
+	cert    *tls.Certificate
+	expires time.Time
+}
+
+// ca holds the lazily-created local CA. ensureCA can be reached
+// concurrently (GetCertificate in a TLS handshake goroutine, and
+// loadTLSConfig from a Reload or the fsnotify watcher goroutine), so
+// this is an atomic pointer rather than a bare package var.
+var ca atomic.Pointer[certAuthority]
+
+// caCreateMu serializes the first-time creation of ca. A bare
+// "Load() == nil, then Store" check is not itself atomic: two
+// concurrent first callers (startup racing an early Reload, or the
+// fsnotify callback) could both see nil, each generate and persist a
+// separate CA keypair, and stomp each other's ca.crt/ca.key.
+var caCreateMu sync.Mutex
+
+// ensureCA returns the local CA, creating and persisting it on first
+// use. Safe to call concurrently: only one caller ever runs
+// loadOrCreateCA, the rest wait on caCreateMu and reuse its result.
+func ensureCA() *certAuthority {
+	if authority := ca.Load(); authority != nil {
+		return authority
+	}
+
+	caCreateMu.Lock()
+	defer caCreateMu.Unlock()
+
+	if authority := ca.Load(); authority != nil {
+		return authority
+	}
+
+	authority, err := loadOrCreateCA()
+	if err != nil {
+		emitErrorToFrontend("Initialize local CA failed, " + err.Error())
+		return nil
+	}
+	ca.Store(authority)
+	return authority
+}
+
+// loadOrCreateCA loads the local CA from disk, generating and
+// persisting a fresh one on first run.
+func loadOrCreateCA() (*certAuthority, error) {
+	dir := userData.ConfigDir()
+	certPath := filepath.Join(dir, caCertFileName)
+	keyPath := filepath.Join(dir, caKeyFileName)
+
+	if cert, key, err := readCAFromDisk(certPath, keyPath); err == nil {
+		return &certAuthority{cert: cert, key: key, cache: make(map[string]*cachedLeaf)}, nil
+	}
+
+	cert, key, err := generateCA()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCAToDisk(certPath, keyPath, cert, key); err != nil {
+		return nil, err
+	}
+	return &certAuthority{cert: cert, key: key, cache: make(map[string]*cachedLeaf)}, nil
+}
+
+func readCAFromDisk(certPath, keyPath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := tlsCert.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("ca key is not an RSA key")
+	}
+	return cert, key, nil
+}
+
+func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKeyHash := sha1.Sum(x509.MarshalPKCS1PublicKey(&key.PublicKey))
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Local Proxy CA",
+			Organization: []string{"Local Proxy"},
+		},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          pubKeyHash[:],
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func writeCAToDisk(certPath, keyPath string, cert *x509.Certificate, key *rsa.PrivateKey) error {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return os.WriteFile(keyPath, keyPEM, 0600)
+}
+
+// newSerialNumber draws a serial number from a large random space so
+// reissued certificates never collide.
+func newSerialNumber() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	return rand.Int(rand.Reader, limit)
+}
+
+// certificateFor returns a leaf certificate for serverName, minting
+// and caching a new one signed by the CA if needed.
+func (a *certAuthority) certificateFor(serverName string) (*tls.Certificate, error) {
+	a.mu.RLock()
+	entry, ok := a.cache[serverName]
+	a.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.cert, nil
+	}
+
+	leaf, err := a.mintLeaf(serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.cache[serverName] = &cachedLeaf{cert: leaf, expires: time.Now().Add(leafCertTTL)}
+	a.mu.Unlock()
+
+	return leaf, nil
+}
+
+func (a *certAuthority) mintLeaf(serverName string) (*tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash := sha1.Sum(x509.MarshalPKCS1PublicKey(&key.PublicKey))
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: serverName,
+		},
+		NotBefore:   time.Now().Add(-1 * time.Hour),
+		NotAfter:    time.Now().AddDate(0, 0, 30),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		// SubjectKeyId mirrors the CA's own derivation (SHA-1 of the
+		// public key) so chains built from either cert look consistent.
+		SubjectKeyId: pubKeyHash[:],
+	}
+
+	if ip := net.ParseIP(serverName); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else if serverName != "" {
+		template.DNSNames = []string{serverName}
+	} else {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, a.cert.Raw},
+		PrivateKey:  key,
+	}, nil
+}
+
+// CACertPath returns the path to the CA certificate so the frontend
+// can offer it up for import into the OS/browser trust store.
+func (s *Service) CACertPath() string {
+	return filepath.Join(userData.ConfigDir(), caCertFileName)
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	defaultFlowBufferSize   = 200
+	defaultFlowBodyCapBytes = 1 << 20 // 1 MiB
+
+	// replayTargetHeader lets ReplayFlow force a target without
+	// relying on a host-name lookup in the routing table.
+	replayTargetHeader = "X-Echo-Proxy-Replay-Target"
+)
+
+// Flow is a recorded request/response pair that passed through the
+// proxy, kept around so it can be inspected or replayed from the UI.
+type Flow struct {
+	ID             int64         `json:"id"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	Target         string        `json:"target"`
+	RequestHeader  http.Header   `json:"requestHeader"`
+	RequestBody    []byte        `json:"requestBody"`
+	StatusCode     int           `json:"statusCode"`
+	ResponseHeader http.Header   `json:"responseHeader"`
+	ResponseBody   []byte        `json:"responseBody"`
+	StartedAt      time.Time     `json:"startedAt"`
+	Duration       time.Duration `json:"duration"`
+	BodyTruncated  bool          `json:"bodyTruncated"`
+	// Error holds the RoundTrip failure (dial timeout, TLS error, ...)
+	// for a request that never got a response, so it still shows up in
+	// history instead of vanishing silently.
+	Error string `json:"error,omitempty"`
+}
+
+// FlowOverrides describes what ReplayFlow should change about a
+// recorded flow before sending it again.
+type FlowOverrides struct {
+	Headers map[string]string `json:"headers"`
+	Body    []byte            `json:"body"`
+	Target  string            `json:"target"`
+}
+
+// flowRecorder is a bounded ring buffer of recently observed flows.
+type flowRecorder struct {
+	mu     sync.Mutex
+	flows  []*Flow
+	nextID int64
+	size   int
+}
+
+func newFlowRecorder(size int) *flowRecorder {
+	if size <= 0 {
+		size = defaultFlowBufferSize
+	}
+	return &flowRecorder{size: size}
+}
+
+func (r *flowRecorder) add(f *Flow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	f.ID = r.nextID
+	r.flows = append(r.flows, f)
+	if len(r.flows) > r.size {
+		r.flows = r.flows[len(r.flows)-r.size:]
+	}
+}
+
+func (r *flowRecorder) list() []*Flow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*Flow, len(r.flows))
+	copy(out, r.flows)
+	return out
+}
+
+func (r *flowRecorder) get(id int64) *Flow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.flows {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+func (r *flowRecorder) last() *Flow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.flows) == 0 {
+		return nil
+	}
+	return r.flows[len(r.flows)-1]
+}
+
+// flows lazily creates the recorder, sized from cfg().Setting.
+func (s *Service) flows() *flowRecorder {
+	s.flowsOnce.Do(func() {
+		s.flowStore = newFlowRecorder(cfg().Setting.FlowBufferSize)
+	})
+	return s.flowStore
+}
+
+func (s *Service) flowBodyCap() int64 {
+	if cfg().Setting.FlowBodyCapBytes > 0 {
+		return cfg().Setting.FlowBodyCapBytes
+	}
+	return defaultFlowBodyCapBytes
+}
+
+// pendingFlow tracks a flow that is still in flight, threaded through
+// the request context from Director to ModifyResponse.
+type pendingFlow struct {
+	flow       *Flow
+	reqBodyBuf *bytes.Buffer
+	// done, when non-nil, receives the finished Flow once recordResponse's
+	// onClose fires, so a caller that needs its own request's result back
+	// (ReplayFlow) doesn't have to guess which flow in the shared recorder
+	// was its own.
+	done chan *Flow
+}
+
+type flowCtxKey struct{}
+
+// replayDoneCtxKey carries the channel ReplayFlow waits on for its own
+// request's finished Flow, set on the request context before it reaches
+// the proxy so recordRequest can wire it into the pendingFlow.
+type replayDoneCtxKey struct{}
+
+// recordRequest starts recording req, tee-ing its body (up to the
+// configured cap) so the upstream still sees the full stream. clientURL
+// is the request as the client sent it, captured by the caller before
+// the Director rewrites req.URL to the upstream target, so Flow.URL
+// and Flow.Target stay distinct even when StripPrefix or a regex
+// rewrite changes the path.
+func (s *Service) recordRequest(req *http.Request, clientURL string, target *url.URL) {
+	targetStr := ""
+	if target != nil {
+		targetStr = target.String()
+	}
+
+	body, buf := teeCappedBody(req.Body, s.flowBodyCap())
+	req.Body = body
+
+	pending := &pendingFlow{
+		flow: &Flow{
+			Method:        req.Method,
+			URL:           clientURL,
+			Target:        targetStr,
+			RequestHeader: req.Header.Clone(),
+			StartedAt:     time.Now(),
+		},
+		reqBodyBuf: buf,
+	}
+	if done, ok := req.Context().Value(replayDoneCtxKey{}).(chan *Flow); ok {
+		pending.done = done
+	}
+
+	*req = *req.WithContext(context.WithValue(req.Context(), flowCtxKey{}, pending))
+}
+
+// recordResponse finishes a pending flow once its body has been fully
+// streamed back to the client, then stores and broadcasts it.
+func (s *Service) recordResponse(resp *http.Response) error {
+	pending, ok := resp.Request.Context().Value(flowCtxKey{}).(*pendingFlow)
+	if !ok {
+		return nil
+	}
+
+	body, buf := teeCappedBody(resp.Body, s.flowBodyCap())
+	resp.Body = &finalizingBody{
+		ReadCloser: body,
+		onClose: func() {
+			truncated := int64(buf.Len()) >= s.flowBodyCap()
+			s.finalizeFlow(pending, resp.StatusCode, resp.Header.Clone(), buf.Bytes(), truncated, "")
+		},
+	}
+	return nil
+}
+
+// recordError finalizes the pending flow for a request that failed
+// before a response ever came back (dial failure, TLS handshake
+// failure, timeout), so it still shows up in ListFlows/ReplayFlow
+// instead of silently vanishing the way it would if only
+// recordResponse ever stored flows.
+func (s *Service) recordError(req *http.Request, err error) {
+	pending, ok := req.Context().Value(flowCtxKey{}).(*pendingFlow)
+	if !ok {
+		return
+	}
+	s.finalizeFlow(pending, http.StatusBadGateway, nil, nil, false, err.Error())
+}
+
+// finalizeFlow fills in the response side of pending's flow and stores
+// it, shared by both the success path (recordResponse) and the error
+// path (recordError) so a failed request is recorded the same way as
+// a completed one.
+func (s *Service) finalizeFlow(pending *pendingFlow, statusCode int, respHeader http.Header, respBody []byte, truncated bool, errMsg string) {
+	pending.flow.StatusCode = statusCode
+	pending.flow.ResponseHeader = respHeader
+	pending.flow.RequestBody = pending.reqBodyBuf.Bytes()
+	pending.flow.ResponseBody = respBody
+	pending.flow.BodyTruncated = truncated
+	pending.flow.Duration = time.Since(pending.flow.StartedAt)
+	pending.flow.Error = errMsg
+
+	s.flows().add(pending.flow)
+	if s.ctx != nil {
+		runtime.EventsEmit(s.ctx, "httpFlow", pending.flow)
+	}
+	if pending.done != nil {
+		pending.done <- pending.flow
+	}
+}
+
+// teeCappedBody returns a replacement body that still yields every
+// byte to its reader, while buf only ever grows up to capBytes.
+func teeCappedBody(body io.ReadCloser, capBytes int64) (io.ReadCloser, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	if body == nil {
+		return body, buf
+	}
+	tee := io.TeeReader(body, &cappedWriter{buf: buf, max: capBytes})
+	return struct {
+		io.Reader
+		io.Closer
+	}{tee, body}, buf
+}
+
+// cappedWriter discards anything past max, but always reports the
+// full length written so io.TeeReader doesn't abort the copy.
+type cappedWriter struct {
+	buf *bytes.Buffer
+	max int64
+	n   int64
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	if c.n < c.max {
+		remain := c.max - c.n
+		if int64(len(p)) > remain {
+			c.buf.Write(p[:remain])
+			c.n = c.max
+		} else {
+			c.buf.Write(p)
+			c.n += int64(len(p))
+		}
+	}
+	return len(p), nil
+}
+
+// finalizingBody runs onClose exactly once, when the consumer (the
+// reverse proxy or a replay handler) is done reading the body.
+type finalizingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	onClose func()
+}
+
+func (f *finalizingBody) Close() error {
+	err := f.ReadCloser.Close()
+	f.once.Do(f.onClose)
+	return err
+}
+
+// ListFlows returns the recently observed request/response flows,
+// most recent last.
+func (s *Service) ListFlows() []*Flow {
+	return s.flows().list()
+}
+
+// GetFlow returns a single recorded flow by ID, or nil if it has
+// already been evicted from the ring buffer.
+func (s *Service) GetFlow(id int64) *Flow {
+	return s.flows().get(id)
+}
+
+// ReplayFlow resends a previously recorded request through the same
+// Director pipeline, optionally overriding its headers, body or
+// target, and returns the newly recorded flow for the replay.
+func (s *Service) ReplayFlow(id int64, overrides FlowOverrides) (*Flow, error) {
+	original := s.flows().get(id)
+	if original == nil {
+		return nil, fmt.Errorf("flow %d not found", id)
+	}
+
+	body := original.RequestBody
+	if overrides.Body != nil {
+		body = overrides.Body
+	}
+
+	req, err := http.NewRequest(original.Method, original.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = original.RequestHeader.Clone()
+	for k, v := range overrides.Headers {
+		req.Header.Set(k, v)
+	}
+
+	target := original.Target
+	if overrides.Target != "" {
+		target = overrides.Target
+	}
+	if target != "" {
+		req.Header.Set(replayTargetHeader, target)
+	}
+
+	// s.flows().last() would be racy under concurrent traffic: another
+	// in-flight request could be recorded between ServeHTTP returning and
+	// the read. Instead, hand the request a channel and let
+	// recordResponse's onClose hand the finished Flow straight back.
+	done := make(chan *Flow, 1)
+	*req = *req.WithContext(context.WithValue(req.Context(), replayDoneCtxKey{}, done))
+
+	s.proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case flow := <-done:
+		return flow, nil
+	case <-time.After(5 * time.Second):
+		// ServeHTTP finalizes the flow before returning, either by closing
+		// the response body (which fires onClose) or through recordError
+		// when the round trip fails, so this only trips if neither ran.
+		return nil, fmt.Errorf("replay of flow %d did not produce a result", id)
+	}
+}
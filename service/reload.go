@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"localProxy/hostsFile"
+)
+
+// Init wires the service to the Wails runtime context and starts
+// listening for the hostsChange event emitted by userData.saveConfig,
+// so edits to hosts/targets/certs take effect without a restart.
+func (s *Service) Init(ctx context.Context) {
+	s.ctx = ctx
+	runtime.EventsOn(ctx, "hostsChange", func(_ ...interface{}) {
+		s.Reload()
+	})
+}
+
+// Reload rebuilds the target map, re-diffs /etc/hosts and swaps in a
+// fresh TLS config in place, so existing connections are left alone
+// and only see the new configuration on their next handshake.
+func (s *Service) Reload() {
+	s.makeRouteTable()
+
+	domains := make([]string, 0, len(cfg().Hosts))
+	for _, h := range cfg().Hosts {
+		domains = append(domains, h.Name)
+	}
+	if err := hostsFile.Sync(domains); err != nil {
+		if errors.Is(err, hostsFile.ErrNotPrivileged) {
+			runtime.EventsEmit(s.ctx, "hostsPermissionRequired")
+		} else {
+			emitErrorToFrontend("Reload /etc/hosts failed, " + err.Error())
+		}
+	}
+
+	s.tlsConfig.Store(s.loadTLSConfig())
+	s.watchCertFiles()
+
+	if s.ctx != nil {
+		runtime.EventsEmit(s.ctx, "certReloaded")
+	}
+}
+
+// watchCertFiles watches every host's explicit TLSCertFile/TLSKeyFile
+// so an external tool re-issuing them on disk is picked up without
+// waiting for the next hostsChange event.
+func (s *Service) watchCertFiles() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		emitWarningToFrontend("Watch TLS certificate files failed, " + err.Error())
+		return
+	}
+
+	for _, h := range cfg().Hosts {
+		if h.EnableTLS && h.TLSCertFile != "" && h.TLSKeyFile != "" {
+			if err := watcher.Add(h.TLSCertFile); err != nil {
+				emitWarningToFrontend("Watch " + h.TLSCertFile + " failed, " + err.Error())
+			}
+			if err := watcher.Add(h.TLSKeyFile); err != nil {
+				emitWarningToFrontend("Watch " + h.TLSKeyFile + " failed, " + err.Error())
+			}
+		}
+	}
+
+	if old := s.certWatcher.Swap(watcher); old != nil {
+		_ = old.Close()
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				log.Println("Detected certificate change at " + event.Name)
+				if newConfig := s.loadTLSConfig(); newConfig != nil {
+					s.tlsConfig.Store(newConfig)
+					if s.ctx != nil {
+						runtime.EventsEmit(s.ctx, "certReloaded")
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				emitWarningToFrontend("Watching TLS certificate files failed, " + err.Error())
+			}
+		}
+	}()
+}
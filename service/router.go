@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// compiledRule is a RouteRule with its path matcher pre-parsed, so
+// the hot path never compiles a regex or does string work beyond the
+// match itself.
+type compiledRule struct {
+	method      string
+	pathPrefix  string
+	pathRegex   *regexp.Regexp
+	target      string
+	stripPrefix string
+	setHeaders  map[string]string
+}
+
+// hostRoutes is the compiled routing table for a single virtual host.
+type hostRoutes struct {
+	rules              []compiledRule
+	defaultTarget      *url.URL
+	insecureSkipVerify bool
+}
+
+// routeCtxKey carries the route resolved for a request from serveHTTP
+// (where the routing table is consulted) to Director (where the
+// rewrite actually happens), so the matching logic lives in one place.
+type routeCtxKey struct{}
+
+type resolvedRoute struct {
+	target *url.URL
+	rule   *compiledRule
+}
+
+// makeRouteTable compiles cfg().Hosts into a routing table, built
+// once and swapped in atomically (guarded by routesMu) on Reload.
+func (s *Service) makeRouteTable() {
+	table := make(map[string]*hostRoutes, len(cfg().Hosts))
+
+	for _, h := range cfg().Hosts {
+		hr := &hostRoutes{insecureSkipVerify: h.InsecureSkipVerify}
+
+		if h.DefaultTarget != "" {
+			if u, err := url.Parse(h.DefaultTarget); err == nil {
+				hr.defaultTarget = u
+			}
+		}
+
+		for _, r := range h.Rules {
+			cr := compiledRule{
+				method:      strings.ToUpper(r.Method),
+				pathPrefix:  r.PathPrefix,
+				target:      r.Target,
+				stripPrefix: r.StripPrefix,
+				setHeaders:  r.SetHeaders,
+			}
+			if r.PathRegex != "" {
+				re, err := regexp.Compile(r.PathRegex)
+				if err != nil {
+					emitWarningToFrontend(fmt.Sprintf("Invalid path regex for %s: %s", h.Name, err.Error()))
+					continue
+				}
+				cr.pathRegex = re
+			}
+			hr.rules = append(hr.rules, cr)
+		}
+
+		table[strings.ToLower(h.Name)] = hr
+	}
+
+	s.routesMu.Lock()
+	s.routes = table
+	s.routesMu.Unlock()
+}
+
+// match returns the first rule whose method and path match req, along
+// with its regex submatches (nil unless a PathRegex rule matched).
+func (hr *hostRoutes) match(req *http.Request) (*compiledRule, []string) {
+	for i := range hr.rules {
+		r := &hr.rules[i]
+		if r.method != "" && r.method != req.Method {
+			continue
+		}
+		if r.pathRegex != nil {
+			if m := r.pathRegex.FindStringSubmatch(req.URL.Path); m != nil {
+				return r, m
+			}
+			continue
+		}
+		if r.pathPrefix != "" {
+			if strings.HasPrefix(req.URL.Path, r.pathPrefix) {
+				return r, nil
+			}
+			continue
+		}
+		return r, nil
+	}
+	return nil, nil
+}
+
+// resolve picks the target for req: the first matching rule, falling
+// back to the host's DefaultTarget (with req's path and query joined
+// onto it), or nil if neither applies.
+func (hr *hostRoutes) resolve(req *http.Request) (*url.URL, *compiledRule) {
+	if rule, submatches := hr.match(req); rule != nil {
+		if u, err := rule.rewrite(req, submatches); err == nil {
+			return u, rule
+		}
+	}
+	if hr.defaultTarget != nil {
+		u := *hr.defaultTarget
+		u.Path = singleJoiningSlash(u.Path, req.URL.Path)
+		u.RawQuery = req.URL.RawQuery
+		return &u, nil
+	}
+	return nil, nil
+}
+
+// rewrite computes the upstream URL for req under this rule. A
+// PathRegex rule treats Target as a regexp replacement template (so
+// "$1" etc. pull in captured groups); otherwise StripPrefix is
+// trimmed from the path before joining it onto Target.
+func (r *compiledRule) rewrite(req *http.Request, submatches []string) (*url.URL, error) {
+	if r.pathRegex != nil && submatches != nil {
+		return url.Parse(r.pathRegex.ReplaceAllString(req.URL.Path, r.target))
+	}
+
+	base, err := url.Parse(r.target)
+	if err != nil {
+		return nil, err
+	}
+
+	path := req.URL.Path
+	if r.stripPrefix != "" {
+		path = strings.TrimPrefix(path, r.stripPrefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	base.Path = singleJoiningSlash(base.Path, path)
+	base.RawQuery = req.URL.RawQuery
+	return base, nil
+}
+
+// singleJoiningSlash mirrors the helper httputil.NewSingleHostReverseProxy
+// uses internally, so target+path joins never end up with "//" or a
+// missing "/" between them.
+func singleJoiningSlash(a, b string) string {
+	aSlash := strings.HasSuffix(a, "/")
+	bSlash := strings.HasPrefix(b, "/")
+	switch {
+	case aSlash && bSlash:
+		return a + b[1:]
+	case !aSlash && !bSlash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestSingleJoiningSlash(t *testing.T) {
+	cases := []struct {
+		a, b, want string
+	}{
+		{"http://example.com/api", "/users", "http://example.com/api/users"},
+		{"http://example.com/api/", "/users", "http://example.com/api/users"},
+		{"http://example.com/api", "users", "http://example.com/api/users"},
+		{"http://example.com/api/", "users", "http://example.com/api/users"},
+		{"http://example.com", "", "http://example.com/"},
+	}
+	for _, c := range cases {
+		if got := singleJoiningSlash(c.a, c.b); got != c.want {
+			t.Errorf("singleJoiningSlash(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestCompiledRuleRewritePrefix(t *testing.T) {
+	r := &compiledRule{
+		target:      "http://upstream:8080",
+		stripPrefix: "/api",
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://host/api/users?id=1", nil)
+
+	u, err := r.rewrite(req, nil)
+	if err != nil {
+		t.Fatalf("rewrite returned error: %v", err)
+	}
+	if got, want := u.String(), "http://upstream:8080/users?id=1"; got != want {
+		t.Errorf("rewrite() = %q, want %q", got, want)
+	}
+}
+
+func TestCompiledRuleRewriteNoStripPrefixMatch(t *testing.T) {
+	r := &compiledRule{target: "http://upstream:8080/base"}
+	req := httptest.NewRequest(http.MethodGet, "http://host/users", nil)
+
+	u, err := r.rewrite(req, nil)
+	if err != nil {
+		t.Fatalf("rewrite returned error: %v", err)
+	}
+	if got, want := u.String(), "http://upstream:8080/base/users"; got != want {
+		t.Errorf("rewrite() = %q, want %q", got, want)
+	}
+}
+
+func TestCompiledRuleRewriteRegex(t *testing.T) {
+	r := &compiledRule{
+		pathRegex: regexp.MustCompile(`^/v1/(\w+)$`),
+		target:    "http://upstream:8080/v2/$1",
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://host/v1/widgets", nil)
+	submatches := r.pathRegex.FindStringSubmatch(req.URL.Path)
+
+	u, err := r.rewrite(req, submatches)
+	if err != nil {
+		t.Fatalf("rewrite returned error: %v", err)
+	}
+	if got, want := u.String(), "http://upstream:8080/v2/widgets"; got != want {
+		t.Errorf("rewrite() = %q, want %q", got, want)
+	}
+}
+
+func TestHostRoutesMatch(t *testing.T) {
+	getRule := compiledRule{method: http.MethodGet, pathPrefix: "/static"}
+	regexRule := compiledRule{pathRegex: regexp.MustCompile(`^/v1/(\w+)$`)}
+	catchAll := compiledRule{}
+
+	hr := &hostRoutes{rules: []compiledRule{getRule, regexRule, catchAll}}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantRule   *compiledRule
+		wantNoSubm bool
+	}{
+		{"method and prefix match", http.MethodGet, "/static/app.js", &hr.rules[0], true},
+		{"wrong method falls through to regex", http.MethodPost, "/v1/widgets", &hr.rules[1], false},
+		{"falls back to catch-all", http.MethodPost, "/anything", &hr.rules[2], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://host"+tt.path, nil)
+			rule, submatches := hr.match(req)
+			if rule != tt.wantRule {
+				t.Fatalf("match() rule = %p, want %p", rule, tt.wantRule)
+			}
+			if tt.wantNoSubm && submatches != nil {
+				t.Errorf("match() submatches = %v, want nil", submatches)
+			}
+		})
+	}
+}
+
+func TestHostRoutesMatchNoRules(t *testing.T) {
+	hr := &hostRoutes{}
+	req := httptest.NewRequest(http.MethodGet, "http://host/", nil)
+	if rule, submatches := hr.match(req); rule != nil || submatches != nil {
+		t.Errorf("match() on empty rule set = (%v, %v), want (nil, nil)", rule, submatches)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cast"
+	"localProxy/userData"
 	"log"
 	"net"
 	"net/http"
@@ -13,18 +15,47 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// cfg returns the live configuration. It must be called fresh at each
+// use site rather than cached in a package var: Init (which populates
+// userData's config) only runs once the Wails runtime context is
+// available, well after package-level vars are initialized.
+func cfg() *userData.ConfigFile {
+	return userData.Config()
+}
+
 type Service struct {
+	ctx context.Context
+
 	httpServerRunning bool
 	tlsServerRunning  bool
-	// Host-to-host map for default targets
-	targetMap map[string]*url.URL
+
+	// routes is the compiled per-host routing table, rebuilt on
+	// Reload and guarded so requests never read it mid-rebuild.
+	routesMu sync.RWMutex
+	routes   map[string]*hostRoutes
+
+	// tlsConfig is swapped in place on Reload; the listener's base
+	// config always serves the current pointer via GetConfigForClient.
+	tlsConfig atomic.Pointer[tls.Config]
 
 	httpServer *http.Server
 	tlsServer  *http.Server
 
+	// certWatcher notices on-disk rotation of explicit TLS cert/key
+	// files and refreshes the TLS config without a full Reload. It is
+	// swapped atomically because Reload can be re-entered by two
+	// hostsChange events in quick succession.
+	certWatcher atomic.Pointer[fsnotify.Watcher]
+
+	// flowStore records recent request/response pairs for inspection
+	// and replay from the UI.
+	flowsOnce sync.Once
+	flowStore *flowRecorder
+
 	proxy *httputil.ReverseProxy
 }
 
@@ -35,21 +66,51 @@ func (s *Service) StartServer() bool {
 		}
 	}()
 
-	s.makeTargetMap()
+	s.makeRouteTable()
 
 	s.proxy = &httputil.ReverseProxy{
-		Rewrite: nil,
+		Rewrite:   nil,
+		Transport: newProxyTransport(),
 		Director: func(req *http.Request) {
-			hostName := strings.ToLower(req.Host)
-			log.Println("Host name is " + hostName)
-			target, ok := s.targetMap[hostName]
-			if ok {
+			log.Println("Host name is " + strings.ToLower(req.Host))
+
+			// Captured before req.URL is rewritten below, so Flow.URL
+			// keeps the client-facing request distinct from Flow.Target.
+			scheme := "http"
+			if req.TLS != nil {
+				scheme = "https"
+			}
+			clientURL := (&url.URL{Scheme: scheme, Host: req.Host, Path: req.URL.Path, RawQuery: req.URL.RawQuery}).String()
+
+			var target *url.URL
+			var rule *compiledRule
+			if raw := req.Header.Get(replayTargetHeader); raw != "" {
+				req.Header.Del(replayTargetHeader)
+				if u, err := url.Parse(raw); err == nil {
+					target = u
+				}
+			} else if resolved, ok := req.Context().Value(routeCtxKey{}).(*resolvedRoute); ok {
+				target = resolved.target
+				rule = resolved.rule
+			}
+
+			if target != nil {
 				req.URL.Scheme = target.Scheme
 				req.URL.Host = target.Host
+				req.URL.Path = target.Path
+				req.URL.RawQuery = target.RawQuery
+			}
+			if rule != nil {
+				for k, v := range rule.setHeaders {
+					req.Header.Set(k, v)
+				}
 			}
+
+			s.recordRequest(req, clientURL, target)
 		},
-		ModifyResponse: nil,
+		ModifyResponse: s.recordResponse,
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.recordError(r, err)
 			w.WriteHeader(502)
 			html := fmt.Sprintf("<div style=\"text-align: center\"><h2>Bad Gateway</h2><p>%s</p>", err.Error())
 			_, _ = w.Write([]byte(html))
@@ -103,6 +164,7 @@ func (s *Service) GetServerStatus() map[string]bool {
 func (s *Service) loadTLSConfig() *tls.Config {
 	tlsConfig := &tls.Config{
 		MinVersion:       tls.VersionTLS12,
+		NextProtos:       []string{"h2", "http/1.1"},
 		CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
 		CipherSuites: []uint16{
 			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
@@ -114,41 +176,78 @@ func (s *Service) loadTLSConfig() *tls.Config {
 		},
 	}
 
-	// Load certificate files. Invalid certificate will be ignored and emit a warning
-	tlsConfig.Certificates = make([]tls.Certificate, 0, len(config.Hosts))
-	for _, h := range config.Hosts {
+	// Explicit per-host certificate files still take priority over the
+	// auto-minted one, keyed by ServerName so overrides can coexist
+	// with the CA for other hosts.
+	overrides := make(map[string]tls.Certificate)
+	for _, h := range cfg().Hosts {
 		if h.EnableTLS && h.TLSCertFile != "" && h.TLSKeyFile != "" {
 			cert, err := tls.LoadX509KeyPair(h.TLSCertFile, h.TLSKeyFile)
 			if err != nil {
 				emitWarningToFrontend(fmt.Sprintf("Load certificate for %s failed\n", h.Name))
 			} else {
-				tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+				overrides[strings.ToLower(h.Name)] = cert
 			}
 		}
 	}
 
+	ensureCA()
+
+	tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		serverName := strings.ToLower(hello.ServerName)
+		if cert, ok := overrides[serverName]; ok {
+			return &cert, nil
+		}
+		authority := ensureCA()
+		if authority == nil {
+			return nil, fmt.Errorf("no local CA available to mint a certificate for %s", serverName)
+		}
+		return authority.certificateFor(serverName)
+	}
+
 	return tlsConfig
 }
 
-func (s *Service) makeTargetMap() {
-	s.targetMap = make(map[string]*url.URL)
-	for _, h := range config.Hosts {
-		if h.DefaultTarget != "" {
-			u, err := url.Parse(h.DefaultTarget)
-			if err == nil {
-				s.targetMap[h.Name] = u
-			}
-		}
+// serveHTTP resolves the route for req against the compiled routing
+// table before handing off to the reverse proxy, so an unconfigured
+// host gets a clear 404 instead of the proxy attempting (and failing)
+// a round trip to a zero-value upstream.
+func (s *Service) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	hostName := strings.ToLower(req.Host)
+
+	s.routesMu.RLock()
+	hr, ok := s.routes[hostName]
+	s.routesMu.RUnlock()
+
+	var target *url.URL
+	var rule *compiledRule
+	if ok {
+		target, rule = hr.resolve(req)
+	}
+
+	if target == nil {
+		w.WriteHeader(http.StatusNotFound)
+		html := fmt.Sprintf("<div style=\"text-align: center\"><h2>Not Found</h2><p>No target configured for %s</p></div>", req.Host)
+		_, _ = w.Write([]byte(html))
+		return
 	}
+
+	ctx := context.WithValue(req.Context(), routeCtxKey{}, &resolvedRoute{target: target, rule: rule})
+	ctx = context.WithValue(ctx, insecureCtxKey{}, hr.insecureSkipVerify)
+	*req = *req.WithContext(ctx)
+	s.proxy.ServeHTTP(w, req)
 }
 
 func (s *Service) startHttpServer(wg *sync.WaitGroup) {
 	s.httpServer = &http.Server{
-		Addr:           ":" + cast.ToString(config.Setting.HttpPort),
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-		Handler:        s.proxy,
+		Addr: ":" + cast.ToString(cfg().Setting.HttpPort),
+		// ReadTimeout/WriteTimeout are left unset (no hard deadline) so
+		// long-lived WebSocket streams aren't killed; ReadHeaderTimeout
+		// and IdleTimeout still bound an idle/slow client.
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+		Handler:           http.HandlerFunc(s.serveHTTP),
 	}
 
 	listener, err := net.Listen("tcp", s.httpServer.Addr)
@@ -167,23 +266,29 @@ func (s *Service) startHttpServer(wg *sync.WaitGroup) {
 }
 
 func (s *Service) startTlsServer(wg *sync.WaitGroup) {
-	tlsConfig := s.loadTLSConfig()
-	// Skip TLS server when no certificate available
-	if len(tlsConfig.Certificates) == 0 {
-		s.tlsServerRunning = false
-		wg.Done()
-		return
+	s.tlsConfig.Store(s.loadTLSConfig())
+	s.watchCertFiles()
+
+	// baseConfig always defers to the current pointer, so a Reload can
+	// swap in new hosts/certs without tearing down the listener.
+	baseConfig := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.tlsConfig.Load(), nil
+		},
 	}
 
 	s.tlsServer = &http.Server{
-		Addr:           ":" + cast.ToString(config.Setting.HttpsPort),
-		TLSConfig:      tlsConfig,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-		Handler:        s.proxy,
-	}
-	listener, err := tls.Listen("tcp", s.tlsServer.Addr, tlsConfig)
+		Addr:      ":" + cast.ToString(cfg().Setting.HttpsPort),
+		TLSConfig: baseConfig,
+		// ReadTimeout/WriteTimeout are left unset (no hard deadline) so
+		// long-lived WebSocket streams aren't killed; ReadHeaderTimeout
+		// and IdleTimeout still bound an idle/slow client.
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+		Handler:           http.HandlerFunc(s.serveHTTP),
+	}
+	listener, err := tls.Listen("tcp", s.tlsServer.Addr, baseConfig)
 	if err != nil {
 		emitErrorToFrontend(err.Error())
 		wg.Done()
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// insecureCtxKey carries a matched host's InsecureSkipVerify setting
+// from serveHTTP down to the transport, since http.Transport has no
+// per-request TLS config of its own.
+type insecureCtxKey struct{}
+
+// proxyTransport is an http.RoundTripper that dials upstreams over
+// HTTP/2 when they support it (falling back to HTTP/1.1 otherwise),
+// picking between a verifying and a skip-verify transport per request
+// so self-signed local dev servers can be proxied to without forcing
+// that on every host.
+type proxyTransport struct {
+	mu       sync.Mutex
+	verify   http.RoundTripper
+	noVerify http.RoundTripper
+}
+
+func newProxyTransport() *proxyTransport {
+	return &proxyTransport{}
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	skipVerify, _ := req.Context().Value(insecureCtxKey{}).(bool)
+	return t.transportFor(skipVerify).RoundTrip(req)
+}
+
+func (t *proxyTransport) transportFor(skipVerify bool) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if skipVerify {
+		if t.noVerify == nil {
+			t.noVerify = buildUpstreamTransport(true)
+		}
+		return t.noVerify
+	}
+	if t.verify == nil {
+		t.verify = buildUpstreamTransport(false)
+	}
+	return t.verify
+}
+
+func buildUpstreamTransport(insecureSkipVerify bool) http.RoundTripper {
+	base := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+	}
+	if err := http2.ConfigureTransport(base); err != nil {
+		emitWarningToFrontend("Enable HTTP/2 upstream transport failed, " + err.Error())
+	}
+	return base
+}
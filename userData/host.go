@@ -0,0 +1,19 @@
+package userData
+
+// HostConfig describes a single proxied virtual host.
+type HostConfig struct {
+	Name          string `json:"name"`
+	EnableTLS     bool   `json:"enableTLS"`
+	TLSCertFile   string `json:"tlsCertFile"`
+	TLSKeyFile    string `json:"tlsKeyFile"`
+	DefaultTarget string `json:"defaultTarget"`
+
+	// Rules lets this host fan out to multiple upstream targets by
+	// method/path instead of always hitting DefaultTarget.
+	Rules []RouteRule `json:"rules"`
+
+	// InsecureSkipVerify skips TLS verification when proxying to this
+	// host's upstream(s), useful for local dev servers with
+	// self-signed certificates.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
@@ -12,10 +12,26 @@ const AppName = "Local Proxy"
 
 var configFilePath string
 
-var config *configFile
+var config *ConfigFile
 
-type configFile struct {
-	Hosts []*HostConfig `json:"hosts"`
+// ConfigFile is the on-disk shape of config.json.
+type ConfigFile struct {
+	Hosts   []*HostConfig `json:"hosts"`
+	Setting SettingConfig `json:"setting"`
+}
+
+// SettingConfig holds global, non-host-specific options.
+type SettingConfig struct {
+	HttpPort  int `json:"httpPort"`
+	HttpsPort int `json:"httpsPort"`
+
+	// FlowBufferSize is how many recent request/response flows are
+	// kept in memory for inspection. Zero uses a sane default.
+	FlowBufferSize int `json:"flowBufferSize"`
+	// FlowBodyCapBytes caps how much of a flow's request/response
+	// body is captured, so large uploads/downloads don't blow up
+	// memory. Zero uses a sane default.
+	FlowBodyCapBytes int64 `json:"flowBodyCapBytes"`
 }
 
 var runtimeCtx context.Context
@@ -38,6 +54,20 @@ func Init(ctx context.Context) {
 	parseConfig()
 }
 
+// Config returns the currently loaded configuration. It is only
+// populated once Init has run; callers must not cache the returned
+// pointer at package-init time, since Init runs after package vars
+// are initialized.
+func Config() *ConfigFile {
+	return config
+}
+
+// ConfigDir returns the directory config.json lives in, so other
+// packages can keep their own data (e.g. the MITM CA) alongside it.
+func ConfigDir() string {
+	return getConfigPath()
+}
+
 func getConfigPath() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -49,7 +79,7 @@ func getConfigPath() string {
 
 func parseConfig() {
 	if !utils.FileExists(configFilePath) {
-		config = &configFile{}
+		config = &ConfigFile{}
 		config.Hosts = make([]*HostConfig, 0)
 	} else {
 		fileContent, err := os.ReadFile(configFilePath)
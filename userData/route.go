@@ -0,0 +1,14 @@
+package userData
+
+// RouteRule lets a single virtual host fan out to several upstream
+// targets based on request method and path. Rules are evaluated in
+// order and the first match wins; PathRegex takes precedence over
+// PathPrefix when both are set.
+type RouteRule struct {
+	Method      string            `json:"method"`
+	PathPrefix  string            `json:"pathPrefix"`
+	PathRegex   string            `json:"pathRegex"`
+	Target      string            `json:"target"`
+	StripPrefix string            `json:"stripPrefix"`
+	SetHeaders  map[string]string `json:"setHeaders"`
+}